@@ -0,0 +1,172 @@
+// Package dbtest holds table-driven conformance tests that any kv.DB
+// implementation can run against itself, so every driver is held to the
+// same behavior.
+package dbtest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/SteelPangolin/go-ndbm/kv"
+)
+
+// RunSuite runs the full kv.DB conformance suite against a fresh DB
+// returned by newDB for each subtest.
+func RunSuite(t *testing.T, newDB func() kv.DB) {
+	t.Run("GetSetHasDelete", func(t *testing.T) { testGetSetHasDelete(t, newDB()) })
+	t.Run("Iterator", func(t *testing.T) { testIterator(t, newDB()) })
+	t.Run("ReverseIterator", func(t *testing.T) { testReverseIterator(t, newDB()) })
+	t.Run("Batch", func(t *testing.T) { testBatch(t, newDB()) })
+}
+
+func testGetSetHasDelete(t *testing.T, db kv.DB) {
+	defer db.Close()
+
+	if has, err := db.Has([]byte("a")); err != nil || has {
+		t.Fatalf("Has on empty DB: %v, %v", has, err)
+	}
+	if value, err := db.Get([]byte("a")); err != nil || value != nil {
+		t.Fatalf("Get on empty DB: %v, %v", value, err)
+	}
+
+	if err := db.Set([]byte("a"), []byte("alphabet")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if has, err := db.Has([]byte("a")); err != nil || !has {
+		t.Fatalf("Has after Set: %v, %v", has, err)
+	}
+	value, err := db.Get([]byte("a"))
+	if err != nil {
+		t.Fatalf("Get after Set: %v", err)
+	}
+	if !bytes.Equal(value, []byte("alphabet")) {
+		t.Fatalf("Get after Set returned %q, want %q", value, "alphabet")
+	}
+
+	if err := db.Set([]byte("a"), []byte("overwritten")); err != nil {
+		t.Fatalf("Set overwrite: %v", err)
+	}
+	value, err = db.Get([]byte("a"))
+	if err != nil || !bytes.Equal(value, []byte("overwritten")) {
+		t.Fatalf("Get after overwrite = %q, %v, want %q", value, err, "overwritten")
+	}
+
+	if err := db.Delete([]byte("a")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if has, err := db.Has([]byte("a")); err != nil || has {
+		t.Fatalf("Has after Delete: %v, %v", has, err)
+	}
+
+	// Deleting a key that's already gone must not be an error.
+	if err := db.Delete([]byte("a")); err != nil {
+		t.Fatalf("Delete of missing key: %v", err)
+	}
+}
+
+func seed(t *testing.T, db kv.DB) {
+	items := map[string]string{
+		"a": "alphabet",
+		"b": "battlement",
+		"c": "carnival",
+		"d": "dinosaur",
+	}
+	for k, v := range items {
+		if err := db.Set([]byte(k), []byte(v)); err != nil {
+			t.Fatalf("Set(%q): %v", k, err)
+		}
+	}
+}
+
+func collect(t *testing.T, it kv.Iterator) []string {
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterator error: %v", err)
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("iterator close: %v", err)
+	}
+	return keys
+}
+
+func testIterator(t *testing.T, db kv.DB) {
+	defer db.Close()
+	seed(t, db)
+
+	it, err := db.Iterator([]byte("b"), []byte("d"))
+	if err != nil {
+		t.Fatalf("Iterator: %v", err)
+	}
+	keys := collect(t, it)
+	want := map[string]bool{"b": true, "c": true}
+	if len(keys) != len(want) {
+		t.Fatalf("Iterator(b, d) visited %v, want keys %v", keys, want)
+	}
+	for _, k := range keys {
+		if !want[k] {
+			t.Errorf("Iterator(b, d) unexpectedly visited %q", k)
+		}
+	}
+
+	it, err = db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatalf("Iterator(nil, nil): %v", err)
+	}
+	if keys := collect(t, it); len(keys) != 4 {
+		t.Errorf("Iterator(nil, nil) visited %v, want 4 keys", keys)
+	}
+}
+
+func testReverseIterator(t *testing.T, db kv.DB) {
+	defer db.Close()
+	seed(t, db)
+
+	it, err := db.ReverseIterator(nil, nil)
+	if err != nil {
+		t.Fatalf("ReverseIterator: %v", err)
+	}
+	if keys := collect(t, it); len(keys) != 4 {
+		t.Errorf("ReverseIterator(nil, nil) visited %v, want 4 keys", keys)
+	}
+}
+
+func testBatch(t *testing.T, db kv.DB) {
+	defer db.Close()
+
+	batch := db.NewBatch()
+	if err := batch.Put([]byte("a"), []byte("alphabet")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := batch.Put([]byte("b"), []byte("battlement")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := batch.Discard(); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	if has, _ := db.Has([]byte("a")); has {
+		t.Fatalf("Discard should not apply staged writes")
+	}
+
+	batch = db.NewBatch()
+	if err := batch.Put([]byte("a"), []byte("alphabet")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := batch.Put([]byte("b"), []byte("battlement")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := batch.Delete([]byte("b")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if has, _ := db.Has([]byte("a")); !has {
+		t.Fatalf("Commit should apply staged Put")
+	}
+	if has, _ := db.Has([]byte("b")); has {
+		t.Fatalf("Commit should apply staged Delete of a key staged in the same batch")
+	}
+}
@@ -0,0 +1,77 @@
+// Package kv defines a common key-value store interface so that callers
+// can depend on an interface instead of a specific backend, such as the
+// cgo-bound *ndbm.NDBM. This mirrors the driver pattern used by projects
+// like cosmos-sdk's db subsystem, where badger, rocksdb, and memdb all
+// implement the same DB interface.
+package kv
+
+// DB is a key-value store with iteration and batched writes.
+// Implementations are not required to be safe for concurrent use;
+// see ndbm.NewSyncDB for a wrapper that serializes access.
+type DB interface {
+	// Get retrieves the value for key, or (nil, nil) if key doesn't exist.
+	Get(key []byte) ([]byte, error)
+
+	// Set inserts or overwrites the value for key.
+	Set(key, value []byte) error
+
+	// Has reports whether key exists in the store.
+	Has(key []byte) (bool, error)
+
+	// Delete removes key from the store. It is not an error to delete a
+	// key that doesn't exist.
+	Delete(key []byte) error
+
+	// Iterator returns an iterator over the half-open range [start, end).
+	// A nil start or end means "from the first key" or "to the last key"
+	// respectively. The caller must call Close on the returned Iterator.
+	Iterator(start, end []byte) (Iterator, error)
+
+	// ReverseIterator is like Iterator, but walks the range in reverse.
+	ReverseIterator(start, end []byte) (Iterator, error)
+
+	// NewBatch returns a Batch for accumulating writes to commit atomically.
+	NewBatch() Batch
+
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// Iterator walks a sequence of key-value pairs in a DB, modeled on
+// goleveldb's iterator API.
+type Iterator interface {
+	// Next advances the iterator and reports whether a value is available.
+	// It must be called once before the first call to Key or Value.
+	Next() bool
+
+	// Key returns the key at the current position.
+	// The returned slice must not be modified and may be invalidated by
+	// the next call to Next or Close.
+	Key() []byte
+
+	// Value returns the value at the current position.
+	// The returned slice must not be modified and may be invalidated by
+	// the next call to Next or Close.
+	Value() []byte
+
+	// Err returns the first error encountered during iteration, if any.
+	Err() error
+
+	// Close releases the resources held by the iterator.
+	Close() error
+}
+
+// Batch accumulates writes to be committed atomically.
+type Batch interface {
+	// Put stages an insert or overwrite of key/value.
+	Put(key, value []byte) error
+
+	// Delete stages a removal of key.
+	Delete(key []byte) error
+
+	// Commit atomically applies every staged operation to the underlying DB.
+	Commit() error
+
+	// Discard abandons the batch without applying any staged operation.
+	Discard() error
+}
@@ -0,0 +1,194 @@
+// Package memdb implements kv.DB as an in-memory sorted map. It's useful
+// for tests, benchmarks, and platforms where ndbm.h isn't available.
+package memdb
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/SteelPangolin/go-ndbm/kv"
+)
+
+// MemDB is an in-memory kv.DB backed by a sorted slice of keys.
+type MemDB struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	keys   []string // kept sorted
+}
+
+// New returns an empty MemDB.
+func New() *MemDB {
+	return &MemDB{
+		values: make(map[string][]byte),
+	}
+}
+
+var _ kv.DB = (*MemDB)(nil)
+
+func (db *MemDB) Get(key []byte) ([]byte, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.values[string(key)], nil
+}
+
+func (db *MemDB) Has(key []byte) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	_, ok := db.values[string(key)]
+	return ok, nil
+}
+
+func (db *MemDB) Set(key, value []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.set(key, value)
+	return nil
+}
+
+// set inserts key/value assuming db.mu is already held.
+func (db *MemDB) set(key, value []byte) {
+	k := string(key)
+	if _, exists := db.values[k]; !exists {
+		i := sort.SearchStrings(db.keys, k)
+		db.keys = append(db.keys, "")
+		copy(db.keys[i+1:], db.keys[i:])
+		db.keys[i] = k
+	}
+	v := make([]byte, len(value))
+	copy(v, value)
+	db.values[k] = v
+}
+
+func (db *MemDB) Delete(key []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.delete(key)
+	return nil
+}
+
+// delete removes key assuming db.mu is already held.
+func (db *MemDB) delete(key []byte) {
+	k := string(key)
+	if _, exists := db.values[k]; !exists {
+		return
+	}
+	delete(db.values, k)
+	i := sort.SearchStrings(db.keys, k)
+	db.keys = append(db.keys[:i], db.keys[i+1:]...)
+}
+
+func (db *MemDB) Close() error {
+	return nil
+}
+
+// memIterator walks a snapshot of db's sorted keys.
+type memIterator struct {
+	db      *MemDB
+	keys    []string
+	pos     int
+	reverse bool
+}
+
+func inRange(key string, start, end []byte) bool {
+	if start != nil && key < string(start) {
+		return false
+	}
+	if end != nil && key >= string(end) {
+		return false
+	}
+	return true
+}
+
+func (db *MemDB) newIterator(start, end []byte, reverse bool) (kv.Iterator, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	keys := make([]string, 0, len(db.keys))
+	for _, k := range db.keys {
+		if inRange(k, start, end) {
+			keys = append(keys, k)
+		}
+	}
+	if reverse {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+	return &memIterator{db: db, keys: keys, pos: -1, reverse: reverse}, nil
+}
+
+func (db *MemDB) Iterator(start, end []byte) (kv.Iterator, error) {
+	return db.newIterator(start, end, false)
+}
+
+func (db *MemDB) ReverseIterator(start, end []byte) (kv.Iterator, error) {
+	return db.newIterator(start, end, true)
+}
+
+func (it *memIterator) Next() bool {
+	it.pos++
+	return it.pos < len(it.keys)
+}
+
+func (it *memIterator) Key() []byte {
+	return []byte(it.keys[it.pos])
+}
+
+func (it *memIterator) Value() []byte {
+	it.db.mu.Lock()
+	defer it.db.mu.Unlock()
+	return it.db.values[it.keys[it.pos]]
+}
+
+func (it *memIterator) Err() error {
+	return nil
+}
+
+func (it *memIterator) Close() error {
+	return nil
+}
+
+// memBatch stages Put/Delete operations for atomic application to a MemDB.
+type memBatch struct {
+	db  *MemDB
+	ops []batchOp
+}
+
+type batchOp struct {
+	delete bool
+	key    []byte
+	value  []byte
+}
+
+func (db *MemDB) NewBatch() kv.Batch {
+	return &memBatch{db: db}
+}
+
+func (b *memBatch) Put(key, value []byte) error {
+	b.ops = append(b.ops, batchOp{key: key, value: value})
+	return nil
+}
+
+func (b *memBatch) Delete(key []byte) error {
+	b.ops = append(b.ops, batchOp{delete: true, key: key})
+	return nil
+}
+
+func (b *memBatch) Commit() error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	for _, op := range b.ops {
+		if op.delete {
+			b.db.delete(op.key)
+		} else {
+			b.db.set(op.key, op.value)
+		}
+	}
+	b.ops = nil
+	return nil
+}
+
+func (b *memBatch) Discard() error {
+	b.ops = nil
+	return nil
+}
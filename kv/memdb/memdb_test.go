@@ -0,0 +1,12 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/SteelPangolin/go-ndbm/kv"
+	"github.com/SteelPangolin/go-ndbm/kv/dbtest"
+)
+
+func TestMemDB(t *testing.T) {
+	dbtest.RunSuite(t, func() kv.DB { return New() })
+}
@@ -0,0 +1,199 @@
+// Package ndbmdriver adapts *ndbm.NDBM to the kv.DB interface, so callers
+// can depend on kv.DB instead of the cgo-bound *ndbm.NDBM directly.
+package ndbmdriver
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/SteelPangolin/go-ndbm"
+	"github.com/SteelPangolin/go-ndbm/kv"
+)
+
+// handle is the subset of *ndbm.NDBM's method surface the driver needs.
+// *ndbm.SyncDB implements it too, so wrapping a SyncDB instead of a bare
+// NDBM is a drop-in way to get a goroutine-safe kv.DB.
+type handle interface {
+	Fetch(key []byte) ([]byte, error)
+	Replace(key, value []byte) error
+	Delete(key []byte) error
+	Items() []ndbm.Item
+	Close()
+}
+
+// Driver wraps an ndbm handle (*ndbm.NDBM or *ndbm.SyncDB) to satisfy kv.DB.
+type Driver struct {
+	db handle
+}
+
+// New wraps db as a kv.DB.
+func New(db handle) *Driver {
+	return &Driver{db: db}
+}
+
+var _ kv.DB = (*Driver)(nil)
+
+func (d *Driver) Get(key []byte) ([]byte, error) {
+	value, err := d.db.Fetch(key)
+	if _, notFound := err.(ndbm.KeyNotFound); notFound {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (d *Driver) Has(key []byte) (bool, error) {
+	_, err := d.db.Fetch(key)
+	if err == nil {
+		return true, nil
+	}
+	if _, notFound := err.(ndbm.KeyNotFound); notFound {
+		return false, nil
+	}
+	return false, err
+}
+
+func (d *Driver) Set(key, value []byte) error {
+	return d.db.Replace(key, value)
+}
+
+func (d *Driver) Delete(key []byte) error {
+	err := d.db.Delete(key)
+	if _, notFound := err.(ndbm.KeyNotFound); notFound {
+		return nil
+	}
+	return err
+}
+
+func (d *Driver) Close() error {
+	d.db.Close()
+	return nil
+}
+
+// sliceIterator walks a pre-sorted, range-filtered snapshot of items.
+// NDBM has no native ordered or range-bounded traversal, so the driver
+// materializes and sorts the whole keyspace up front.
+type sliceIterator struct {
+	items []ndbm.Item
+	pos   int
+}
+
+func inRange(key, start, end []byte) bool {
+	if start != nil && bytes.Compare(key, start) < 0 {
+		return false
+	}
+	if end != nil && bytes.Compare(key, end) >= 0 {
+		return false
+	}
+	return true
+}
+
+func (d *Driver) newIterator(start, end []byte, reverse bool) (kv.Iterator, error) {
+	all := d.db.Items()
+	items := make([]ndbm.Item, 0, len(all))
+	for _, item := range all {
+		if inRange(item.Key, start, end) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return bytes.Compare(items[i].Key, items[j].Key) < 0
+	})
+	if reverse {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	return &sliceIterator{items: items, pos: -1}, nil
+}
+
+func (d *Driver) Iterator(start, end []byte) (kv.Iterator, error) {
+	return d.newIterator(start, end, false)
+}
+
+func (d *Driver) ReverseIterator(start, end []byte) (kv.Iterator, error) {
+	return d.newIterator(start, end, true)
+}
+
+func (it *sliceIterator) Next() bool {
+	it.pos++
+	return it.pos < len(it.items)
+}
+
+func (it *sliceIterator) Key() []byte {
+	return it.items[it.pos].Key
+}
+
+func (it *sliceIterator) Value() []byte {
+	return it.items[it.pos].Value
+}
+
+func (it *sliceIterator) Err() error {
+	return nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+// sliceBatch stages Put/Delete operations for application against the
+// underlying NDBM handle. NDBM has no transactions, so this offers no
+// atomicity guarantee on its own: it's only used as a fallback for handle
+// implementations that don't expose a journaled ndbm.Batch.
+type sliceBatch struct {
+	db  handle
+	ops []batchOp
+}
+
+type batchOp struct {
+	delete bool
+	key    []byte
+	value  []byte
+}
+
+// NewBatch returns a kv.Batch that honors kv.Batch.Commit's atomicity
+// promise by delegating to the underlying handle's own journaled
+// ndbm.Batch/ndbm.SyncBatch, both of which already satisfy kv.Batch.
+func (d *Driver) NewBatch() kv.Batch {
+	switch db := d.db.(type) {
+	case *ndbm.NDBM:
+		return db.NewBatch()
+	case *ndbm.SyncDB:
+		return db.NewBatch()
+	default:
+		return &sliceBatch{db: d.db}
+	}
+}
+
+func (b *sliceBatch) Put(key, value []byte) error {
+	b.ops = append(b.ops, batchOp{key: key, value: value})
+	return nil
+}
+
+func (b *sliceBatch) Delete(key []byte) error {
+	b.ops = append(b.ops, batchOp{delete: true, key: key})
+	return nil
+}
+
+func (b *sliceBatch) Commit() error {
+	for _, op := range b.ops {
+		var err error
+		if op.delete {
+			err = b.db.Delete(op.key)
+			if _, notFound := err.(ndbm.KeyNotFound); notFound {
+				err = nil
+			}
+		} else {
+			err = b.db.Replace(op.key, op.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	b.ops = nil
+	return nil
+}
+
+func (b *sliceBatch) Discard() error {
+	b.ops = nil
+	return nil
+}
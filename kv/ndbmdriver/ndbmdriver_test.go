@@ -0,0 +1,30 @@
+package ndbmdriver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SteelPangolin/go-ndbm"
+	"github.com/SteelPangolin/go-ndbm/kv"
+	"github.com/SteelPangolin/go-ndbm/kv/dbtest"
+)
+
+func TestDriver(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "TestNDBMDriver")
+	if err != nil {
+		t.Fatalf("Couldn't create tempdir for test DB: %v", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	i := 0
+	dbtest.RunSuite(t, func() kv.DB {
+		i++
+		db, err := ndbm.OpenWithDefaults(filepath.Join(tempdir, string(rune('a'+i))))
+		if err != nil {
+			t.Fatalf("Couldn't open DB: %v", err)
+		}
+		return New(db)
+	})
+}
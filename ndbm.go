@@ -53,14 +53,32 @@ import "C"
 import (
 	"fmt"
 	"os"
+	"sync"
 	"syscall"
 	"unsafe"
 )
 
 // NDBM or compatible database.
-// NDBM is not required by POSIX to be threadsafe, so this library isn't either.
+// NDBM is not required by POSIX to be threadsafe, so this library isn't
+// either in general; the mutex below only keeps Insert/Replace/Delete from
+// running concurrently with an open Iterator (and with each other), since
+// that's exactly the kind of mutation that can invalidate NDBM's internal
+// dbm_firstkey/dbm_nextkey hash walk — which is also why the lock is a
+// plain exclusive Mutex rather than an RWMutex: NDBM's hash walk lives in
+// one process-wide cursor in the underlying *C.DBM, so two iterators
+// opened at once would drive and corrupt the same cursor, not just race
+// harmlessly like two concurrent readers would. It does NOT make other
+// methods, or the handle as a whole, safe to share across goroutines; see
+// NewSyncDB for that. Because the lock is held for an Iterator's entire
+// lifetime, only one Iterator (or one call to Keys/Values/Items/Len/
+// KeysCallback, which open one internally) may be open on a given NDBM at
+// a time: a second one opened from another goroutine blocks until the
+// first is Closed, and a second one opened from the same goroutine
+// deadlocks, since sync.Mutex isn't reentrant.
 type NDBM struct {
 	cDbm *C.DBM
+	path string
+	mu   sync.Mutex
 }
 
 // KeyAlreadyExists is returned when trying to insert a key that already exists.
@@ -109,6 +127,8 @@ func OpenWithDefaults(path string) (*NDBM, error) {
 }
 
 // Open lets you specify how the database is opened, for example, if you want read-only mode.
+// If a committed write-ahead journal is found from a previous process that
+// crashed mid-Commit, it's replayed into the database before Open returns.
 func Open(path string, flags, mode int) (*NDBM, error) {
 	cPath := C.CString(path)
 	cDbm, err := C.dbm_open(cPath, C.int(flags), C.mode_t(mode))
@@ -118,6 +138,11 @@ func Open(path string, flags, mode int) (*NDBM, error) {
 	}
 	ndbm := &NDBM{
 		cDbm: cDbm,
+		path: path,
+	}
+	if err := ndbm.recoverJournal(); err != nil {
+		ndbm.Close()
+		return nil, err
 	}
 	return ndbm, nil
 }
@@ -173,6 +198,9 @@ func datumToBytes(datum C.datum) []byte {
 }
 
 func (ndbm *NDBM) store(key, value []byte, mode C.int) (C.int, error) {
+	ndbm.mu.Lock()
+	defer ndbm.mu.Unlock()
+
 	C.dbm_clearerr(ndbm.cDbm)
 	status, err := C.dbm_store(ndbm.cDbm, bytesToDatum(key), bytesToDatum(value), mode)
 	if status == checkError {
@@ -228,6 +256,9 @@ func (ndbm *NDBM) Fetch(key []byte) ([]byte, error) {
 // Delete deletes an entry from the database.
 // Returns KeyNotFound if the key can't be found.
 func (ndbm *NDBM) Delete(key []byte) error {
+	ndbm.mu.Lock()
+	defer ndbm.mu.Unlock()
+
 	C.dbm_clearerr(ndbm.cDbm)
 	status, err := C.dbm_delete(ndbm.cDbm, bytesToDatum(key))
 	if status == checkError {
@@ -283,19 +314,14 @@ func (ndbm *NDBM) nextKey() ([]byte, error) {
 // KeysCallback executes a callback function for every key in the database.
 // The callback should take a key and return an error if there is a problem.
 func (ndbm *NDBM) KeysCallback(callback func([]byte) error) error {
-	for key, err := ndbm.firstKey(); err == nil; key, err = ndbm.nextKey() {
-		if err != nil {
-			if err == errNoMoreKeys {
-				return nil
-			}
-			return err
-		}
-		err = callback(key)
-		if err != nil {
+	it := ndbm.NewIterator()
+	defer it.Close()
+	for it.Next() {
+		if err := callback(it.Key()); err != nil {
 			return err
 		}
 	}
-	return nil
+	return it.Err()
 }
 
 // Keys lists every key in the database.
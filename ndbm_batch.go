@@ -0,0 +1,275 @@
+package ndbm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Batch accumulates Put and Delete operations and applies them to an NDBM
+// database all at once. NDBM itself has no transactions, so atomicity is
+// implemented by first writing the batch to a side-car write-ahead journal
+// file (path + ".wal") and only replaying it into the database once the
+// journal is known to be complete, in the spirit of bolt's and leveldb's
+// batched writes.
+type Batch struct {
+	ndbm *NDBM
+	ops  []batchOp
+}
+
+type batchOp struct {
+	del   bool
+	key   []byte
+	value []byte
+}
+
+// commitMarker is appended to the journal once every staged record has been
+// written and fsynced. Its length (4 bytes) can never collide with a record,
+// since the smallest possible record body (an empty key and value) is still
+// 9 bytes, so a 4-byte tail can only ever be the marker.
+var commitMarker = [4]byte{0xDE, 0xAD, 0xBE, 0xEF}
+
+// NewBatch returns an empty Batch for staging writes against ndbm.
+func (ndbm *NDBM) NewBatch() *Batch {
+	return &Batch{ndbm: ndbm}
+}
+
+// Put stages an insert or overwrite of key/value.
+func (b *Batch) Put(key, value []byte) error {
+	b.ops = append(b.ops, batchOp{key: key, value: value})
+	return nil
+}
+
+// Delete stages a removal of key.
+func (b *Batch) Delete(key []byte) error {
+	b.ops = append(b.ops, batchOp{del: true, key: key})
+	return nil
+}
+
+// Discard abandons every staged operation without touching the database.
+func (b *Batch) Discard() error {
+	b.ops = nil
+	return nil
+}
+
+func (ndbm *NDBM) journalPath() string {
+	return ndbm.path + ".wal"
+}
+
+// Commit writes the batch's journal, fsyncs it and its containing
+// directory, applies every staged operation to the database under an
+// exclusive lock, and then removes the journal file. If the process crashes
+// before the journal is fully written and fsynced, the next Open will find
+// no commit marker and discard it; the database is left exactly as it was
+// before Commit was called.
+func (b *Batch) Commit() error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+
+	path := b.ndbm.journalPath()
+	journal, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	for _, op := range b.ops {
+		if err := writeRecord(journal, op); err != nil {
+			return err
+		}
+	}
+	if err := journal.Sync(); err != nil {
+		return err
+	}
+
+	// Acquire the exclusive lock before marking the journal committed: if
+	// locking fails, Commit returns an error and the journal is left
+	// without a commit marker, so the next Open correctly discards it
+	// instead of replaying a batch this call reported as failed.
+	if err := b.ndbm.LockExclusive(); err != nil {
+		return err
+	}
+	defer b.ndbm.Unlock()
+
+	if _, err := journal.Write(commitMarker[:]); err != nil {
+		return err
+	}
+	if err := journal.Sync(); err != nil {
+		return err
+	}
+	// fsync the containing directory too, so the journal's own directory
+	// entry (and not just its contents) survives a crash right after Commit.
+	if err := fsyncDir(path); err != nil {
+		return err
+	}
+
+	for _, op := range b.ops {
+		if err := b.ndbm.applyOp(op); err != nil {
+			return err
+		}
+	}
+	b.ops = nil
+
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return fsyncDir(path)
+}
+
+// fsyncDir fsyncs the directory containing path, so that a directory entry
+// created or removed at path (as opposed to just its file contents) is
+// durable across a crash.
+func fsyncDir(path string) error {
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (ndbm *NDBM) applyOp(op batchOp) error {
+	if op.del {
+		err := ndbm.Delete(op.key)
+		if _, notFound := err.(KeyNotFound); notFound {
+			return nil
+		}
+		return err
+	}
+	return ndbm.Replace(op.key, op.value)
+}
+
+// recoverJournal replays a committed journal left behind by a previous
+// process, if any, and discards an uncommitted one. It's called once from
+// Open, before the handle is returned to the caller.
+func (ndbm *NDBM) recoverJournal() error {
+	path := ndbm.journalPath()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	ops, committed := parseJournal(data)
+	if !committed {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return fsyncDir(path)
+	}
+
+	if err := ndbm.LockExclusive(); err != nil {
+		return err
+	}
+	defer ndbm.Unlock()
+
+	for _, op := range ops {
+		if err := ndbm.applyOp(op); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return fsyncDir(path)
+}
+
+// writeRecord appends one length-prefixed, checksummed record to w:
+// [4 bytes body length][body][4 bytes CRC-32 of body].
+// The body is [1 byte op][4 bytes key length][key][4 bytes value length][value].
+func writeRecord(w io.Writer, op batchOp) error {
+	body := new(bytes.Buffer)
+	if op.del {
+		body.WriteByte(recordDelete)
+	} else {
+		body.WriteByte(recordPut)
+	}
+	binary.Write(body, binary.BigEndian, uint32(len(op.key)))
+	body.Write(op.key)
+	binary.Write(body, binary.BigEndian, uint32(len(op.value)))
+	body.Write(op.value)
+
+	record := new(bytes.Buffer)
+	binary.Write(record, binary.BigEndian, uint32(body.Len()))
+	record.Write(body.Bytes())
+	binary.Write(record, binary.BigEndian, crc32.ChecksumIEEE(body.Bytes()))
+
+	_, err := w.Write(record.Bytes())
+	return err
+}
+
+const (
+	recordPut    = 1
+	recordDelete = 2
+)
+
+// parseJournal decodes every complete, checksum-valid record from data and
+// reports whether the journal ends in a valid commit marker. Any truncated
+// or corrupt tail (from a crash mid-write) is treated as "not committed",
+// and whatever records were decoded up to that point are discarded by the
+// caller along with the journal file.
+func parseJournal(data []byte) (ops []batchOp, committed bool) {
+	pos := 0
+	for {
+		remaining := len(data) - pos
+		if remaining == len(commitMarker) && bytes.Equal(data[pos:], commitMarker[:]) {
+			return ops, true
+		}
+		if remaining < 4 {
+			return ops, false
+		}
+
+		bodyLen := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		recordLen := 4 + bodyLen + 4
+		if remaining < recordLen {
+			return ops, false
+		}
+
+		body := data[pos+4 : pos+4+bodyLen]
+		storedCRC := binary.BigEndian.Uint32(data[pos+4+bodyLen : pos+recordLen])
+		if crc32.ChecksumIEEE(body) != storedCRC {
+			return ops, false
+		}
+
+		op, err := decodeRecordBody(body)
+		if err != nil {
+			return ops, false
+		}
+		ops = append(ops, op)
+		pos += recordLen
+	}
+}
+
+func decodeRecordBody(body []byte) (batchOp, error) {
+	if len(body) < 9 {
+		return batchOp{}, fmt.Errorf("ndbm: journal record too short: %d bytes", len(body))
+	}
+	op := body[0]
+	keyLen := int(binary.BigEndian.Uint32(body[1:5]))
+	if len(body) < 5+keyLen+4 {
+		return batchOp{}, fmt.Errorf("ndbm: journal record key overruns body")
+	}
+	key := body[5 : 5+keyLen]
+	valueLen := int(binary.BigEndian.Uint32(body[5+keyLen : 5+keyLen+4]))
+	if len(body) != 5+keyLen+4+valueLen {
+		return batchOp{}, fmt.Errorf("ndbm: journal record value overruns body")
+	}
+	value := body[5+keyLen+4 : 5+keyLen+4+valueLen]
+
+	switch op {
+	case recordPut:
+		return batchOp{key: append([]byte{}, key...), value: append([]byte{}, value...)}, nil
+	case recordDelete:
+		return batchOp{del: true, key: append([]byte{}, key...)}, nil
+	default:
+		return batchOp{}, fmt.Errorf("ndbm: unknown journal record op %d", op)
+	}
+}
@@ -0,0 +1,86 @@
+package ndbm
+
+// Iterator walks every key in an NDBM database, modeled on goleveldb's
+// iterator API. It holds an exclusive lock on the database for its entire
+// lifetime, so that neither a concurrent Insert/Replace/Delete nor a
+// second concurrent Iterator can invalidate the underlying
+// dbm_firstkey/dbm_nextkey hash walk out from under it (NDBM keeps that
+// walk's cursor in one process-wide place, so two iterators driving it at
+// once would corrupt each other's position, not just race harmlessly).
+// Only one Iterator (or one call to Keys/Values/Items/Len/KeysCallback,
+// which open one internally) may be open on a given NDBM at a time: a
+// second one opened from another goroutine blocks until the first is
+// Closed, and a second one opened from the same goroutine deadlocks.
+type Iterator struct {
+	ndbm    *NDBM
+	key     []byte
+	value   []byte
+	err     error
+	started bool
+	done    bool
+}
+
+// NewIterator returns an Iterator over every key in the database.
+// The caller must call Close when done with it.
+func (ndbm *NDBM) NewIterator() *Iterator {
+	ndbm.mu.Lock()
+	return &Iterator{ndbm: ndbm}
+}
+
+// Next advances the iterator and reports whether a key/value pair is
+// available. It must be called once before the first call to Key or Value.
+func (it *Iterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	var key []byte
+	var err error
+	if !it.started {
+		it.started = true
+		key, err = it.ndbm.firstKey()
+	} else {
+		key, err = it.ndbm.nextKey()
+	}
+	if err != nil {
+		it.done = true
+		if err != errNoMoreKeys {
+			it.err = err
+		}
+		return false
+	}
+
+	value, err := it.ndbm.Fetch(key)
+	if err != nil {
+		it.done = true
+		it.err = err
+		return false
+	}
+
+	// firstKey/nextKey/Fetch all copy their datum out of NDBM's reused
+	// internal buffer, so key and value are safe to hold onto.
+	it.key = key
+	it.value = value
+	return true
+}
+
+// Key returns the key at the current position.
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value at the current position.
+func (it *Iterator) Value() []byte {
+	return it.value
+}
+
+// Err returns the first error encountered during iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close releases the iterator's lock on the database.
+func (it *Iterator) Close() {
+	it.done = true
+	it.ndbm.mu.Unlock()
+}
@@ -0,0 +1,81 @@
+package ndbm
+
+// This file rounds out NDBM's CRUD surface with higher-level helpers
+// borrowed from Ruby's DBM class, so *NDBM is usable as a persistent
+// map[string][]byte replacement.
+
+// Has reports whether key exists in the database.
+func (ndbm *NDBM) Has(key []byte) bool {
+	_, err := ndbm.Fetch(key)
+	return err == nil
+}
+
+// Clear deletes every key in the database.
+// Deleting while iterating is undefined in NDBM, so every key is
+// snapshotted into a slice first, then deleted under an exclusive lock.
+func (ndbm *NDBM) Clear() error {
+	keys := ndbm.Keys()
+
+	if err := ndbm.LockExclusive(); err != nil {
+		return err
+	}
+	defer ndbm.Unlock()
+
+	for _, key := range keys {
+		err := ndbm.Delete(key)
+		if _, notFound := err.(KeyNotFound); notFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToMap returns every entry in the database as a map[string][]byte.
+func (ndbm *NDBM) ToMap() map[string][]byte {
+	m := make(map[string][]byte)
+	_ = ndbm.ItemsCallback(func(key, value []byte) error {
+		m[string(key)] = value
+		return nil
+	})
+	return m
+}
+
+// Merge upserts every entry of m into the database.
+func (ndbm *NDBM) Merge(m map[string][]byte) error {
+	for key, value := range m {
+		if err := ndbm.Replace([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filter returns every entry for which predicate returns true.
+func (ndbm *NDBM) Filter(predicate func(key, value []byte) bool) []Item {
+	items := []Item{}
+	_ = ndbm.ItemsCallback(func(key, value []byte) error {
+		if predicate(key, value) {
+			items = append(items, Item{Key: key, Value: value})
+		}
+		return nil
+	})
+	return items
+}
+
+// Invert returns a map from value to key, for every entry in the database.
+// As in Ruby's DBM#invert, if two entries share a value, the key that wins
+// is unspecified.
+func (ndbm *NDBM) Invert() (map[string][]byte, error) {
+	inverted := make(map[string][]byte)
+	err := ndbm.ItemsCallback(func(key, value []byte) error {
+		inverted[string(value)] = key
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inverted, nil
+}
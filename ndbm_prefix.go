@@ -0,0 +1,144 @@
+package ndbm
+
+import "bytes"
+
+// PrefixDB is a view over an NDBM database that transparently prepends a
+// fixed prefix to every key on write and strips it on read, mirroring the
+// "prefix" sub-package cosmos-sdk ships over its DB interface. It lets
+// multiple independent tenants share one .db file without manual key
+// munging at every call site.
+//
+// NDBM has no native range scans, so iteration still walks the entire
+// underlying hash and discards every key that doesn't carry the prefix.
+type PrefixDB struct {
+	ndbm   *NDBM
+	prefix []byte
+}
+
+// Prefix returns a PrefixDB scoping every key under ndbm to the given prefix.
+func (ndbm *NDBM) Prefix(prefix []byte) *PrefixDB {
+	return &PrefixDB{
+		ndbm:   ndbm,
+		prefix: append([]byte{}, prefix...),
+	}
+}
+
+func (p *PrefixDB) prefixed(key []byte) []byte {
+	prefixed := make([]byte, 0, len(p.prefix)+len(key))
+	prefixed = append(prefixed, p.prefix...)
+	prefixed = append(prefixed, key...)
+	return prefixed
+}
+
+// Insert inserts a new entry into the database.
+// Returns KeyAlreadyExists if the key already exists.
+func (p *PrefixDB) Insert(key, value []byte) error {
+	err := p.ndbm.Insert(p.prefixed(key), value)
+	if _, exists := err.(KeyAlreadyExists); exists {
+		return KeyAlreadyExists{Key: key}
+	}
+	return err
+}
+
+// Replace inserts a new entry or overwrites an existing entry.
+func (p *PrefixDB) Replace(key, value []byte) error {
+	return p.ndbm.Replace(p.prefixed(key), value)
+}
+
+// Fetch retrieves an entry value by key.
+// Returns KeyNotFound if the key can't be found.
+func (p *PrefixDB) Fetch(key []byte) ([]byte, error) {
+	value, err := p.ndbm.Fetch(p.prefixed(key))
+	if _, notFound := err.(KeyNotFound); notFound {
+		return nil, KeyNotFound{Key: key}
+	}
+	return value, err
+}
+
+// Delete deletes an entry from the database.
+// Returns KeyNotFound if the key can't be found.
+func (p *PrefixDB) Delete(key []byte) error {
+	err := p.ndbm.Delete(p.prefixed(key))
+	if _, notFound := err.(KeyNotFound); notFound {
+		return KeyNotFound{Key: key}
+	}
+	return err
+}
+
+// PrefixIterator walks every key under a PrefixDB's prefix, stripping the
+// prefix as it goes.
+type PrefixIterator struct {
+	it     *Iterator
+	prefix []byte
+	key    []byte
+	value  []byte
+}
+
+// NewIterator returns an Iterator over every key in the PrefixDB.
+// The caller must call Close when done with it.
+func (p *PrefixDB) NewIterator() *PrefixIterator {
+	return &PrefixIterator{
+		it:     p.ndbm.NewIterator(),
+		prefix: p.prefix,
+	}
+}
+
+// Next advances the iterator past any key outside the prefix and reports
+// whether a key/value pair is available.
+func (pit *PrefixIterator) Next() bool {
+	for pit.it.Next() {
+		key := pit.it.Key()
+		if !bytes.HasPrefix(key, pit.prefix) {
+			continue
+		}
+		pit.key = key[len(pit.prefix):]
+		pit.value = pit.it.Value()
+		return true
+	}
+	return false
+}
+
+// Key returns the current key, with the prefix stripped.
+func (pit *PrefixIterator) Key() []byte {
+	return pit.key
+}
+
+// Value returns the value at the current position.
+func (pit *PrefixIterator) Value() []byte {
+	return pit.value
+}
+
+// Err returns the first error encountered during iteration, if any.
+func (pit *PrefixIterator) Err() error {
+	return pit.it.Err()
+}
+
+// Close releases the iterator's lock on the underlying database.
+func (pit *PrefixIterator) Close() {
+	pit.it.Close()
+}
+
+// Keys lists every key under the prefix, with the prefix stripped.
+func (p *PrefixDB) Keys() [][]byte {
+	it := p.NewIterator()
+	defer it.Close()
+	keys := [][]byte{}
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	return keys
+}
+
+// Items returns every entry under the prefix, with the prefix stripped from each key.
+func (p *PrefixDB) Items() []Item {
+	it := p.NewIterator()
+	defer it.Close()
+	items := []Item{}
+	for it.Next() {
+		items = append(items, Item{
+			Key:   it.Key(),
+			Value: it.Value(),
+		})
+	}
+	return items
+}
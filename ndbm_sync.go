@@ -0,0 +1,165 @@
+package ndbm
+
+import "sync"
+
+// SyncDB wraps an *NDBM and serializes every call through a sync.RWMutex,
+// since NDBM is not required by POSIX to be threadsafe and isn't safe to
+// share across goroutines on its own. It exposes only a subset of *NDBM's
+// methods (Insert, Replace, Fetch, Delete, Keys, Items, Len, Close,
+// NewIterator and NewBatch); the others, such as Values, Update, the
+// callback helpers and the map helpers, are not wrapped. That subset is
+// enough for it to stand in for a *NDBM in kv/ndbmdriver, whose Driver
+// accepts either.
+type SyncDB struct {
+	mu   sync.RWMutex
+	ndbm *NDBM
+}
+
+// NewSyncDB wraps ndbm so that it can be shared across goroutines.
+// The caller should no longer use ndbm directly once wrapped.
+func NewSyncDB(ndbm *NDBM) *SyncDB {
+	return &SyncDB{ndbm: ndbm}
+}
+
+// Insert inserts a new entry into the database.
+// Returns KeyAlreadyExists if the key already exists.
+func (s *SyncDB) Insert(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ndbm.Insert(key, value)
+}
+
+// Replace inserts a new entry or overwrites an existing entry.
+func (s *SyncDB) Replace(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ndbm.Replace(key, value)
+}
+
+// Fetch retrieves an entry value by key.
+// Returns KeyNotFound if the key can't be found.
+func (s *SyncDB) Fetch(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ndbm.Fetch(key)
+}
+
+// Delete deletes an entry from the database.
+// Returns KeyNotFound if the key can't be found.
+func (s *SyncDB) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ndbm.Delete(key)
+}
+
+// Keys lists every key in the database.
+func (s *SyncDB) Keys() [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ndbm.Keys()
+}
+
+// Items returns every entry in the database.
+func (s *SyncDB) Items() []Item {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ndbm.Items()
+}
+
+// Len returns the number of entries in the database.
+func (s *SyncDB) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ndbm.Len()
+}
+
+// Close closes the underlying NDBM database.
+func (s *SyncDB) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ndbm.Close()
+}
+
+// SyncIterator wraps an Iterator and holds SyncDB's write lock for its
+// entire lifetime, on top of the Iterator's own lock on the NDBM handle.
+// It must be exclusive, not shared: the dbm_firstkey/dbm_nextkey cursor
+// lives in one process-wide place in the underlying *C.DBM, so two
+// goroutines iterating "concurrently" would drive and corrupt the very
+// same cursor, not just benignly race like two concurrent reads would. A
+// plain RLock only keeps writers out, which isn't enough here.
+type SyncIterator struct {
+	s  *SyncDB
+	it *Iterator
+}
+
+// NewIterator returns an Iterator over every key in the database.
+// The caller must call Close when done with it.
+func (s *SyncDB) NewIterator() *SyncIterator {
+	s.mu.Lock()
+	return &SyncIterator{s: s, it: s.ndbm.NewIterator()}
+}
+
+// Next advances the iterator and reports whether a key/value pair is available.
+func (it *SyncIterator) Next() bool {
+	return it.it.Next()
+}
+
+// Key returns the key at the current position.
+func (it *SyncIterator) Key() []byte {
+	return it.it.Key()
+}
+
+// Value returns the value at the current position.
+func (it *SyncIterator) Value() []byte {
+	return it.it.Value()
+}
+
+// Err returns the first error encountered during iteration, if any.
+func (it *SyncIterator) Err() error {
+	return it.it.Err()
+}
+
+// Close releases the iterator's locks on the database.
+func (it *SyncIterator) Close() {
+	it.it.Close()
+	it.s.mu.Unlock()
+}
+
+// SyncBatch wraps a Batch so that staging Put/Delete is unguarded (the
+// batch isn't shared until Commit), but Commit takes SyncDB's write lock
+// for the same reason Batch.Commit takes NDBM's exclusive file lock:
+// to keep the replay atomic with respect to other callers of the same SyncDB.
+type SyncBatch struct {
+	s     *SyncDB
+	batch *Batch
+}
+
+// NewBatch returns an empty Batch for staging writes against the database.
+func (s *SyncDB) NewBatch() *SyncBatch {
+	s.mu.RLock()
+	batch := s.ndbm.NewBatch()
+	s.mu.RUnlock()
+	return &SyncBatch{s: s, batch: batch}
+}
+
+// Put stages an insert or overwrite of key/value.
+func (b *SyncBatch) Put(key, value []byte) error {
+	return b.batch.Put(key, value)
+}
+
+// Delete stages a removal of key.
+func (b *SyncBatch) Delete(key []byte) error {
+	return b.batch.Delete(key)
+}
+
+// Discard abandons every staged operation without touching the database.
+func (b *SyncBatch) Discard() error {
+	return b.batch.Discard()
+}
+
+// Commit atomically applies every staged operation to the database.
+func (b *SyncBatch) Commit() error {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+	return b.batch.Commit()
+}
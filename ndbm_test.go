@@ -2,10 +2,12 @@ package ndbm
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"testing"
 )
 
@@ -168,3 +170,408 @@ func TestNDBM(t *testing.T) {
 		}
 	}
 }
+
+func TestIterator(t *testing.T) {
+	// Create a temp dir for test files.
+	tempdir, err := ioutil.TempDir("", "TestIterator")
+	if err != nil {
+		t.Fatalf("Couldn't create tempdir for test DB: %v", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	// Create a new DB in that temp dir.
+	ndbm, err := OpenWithDefaults(filepath.Join(tempdir, "test"))
+	if err != nil {
+		t.Fatalf("Couldn't open DB: %v", err)
+	}
+	defer ndbm.Close()
+
+	expected := items{
+		Item{[]byte("a"), []byte("alphabet")},
+		Item{[]byte("b"), []byte("battlement")},
+		Item{[]byte("c"), []byte("carnival")},
+	}
+	if err := ndbm.Update(expected); err != nil {
+		t.Fatalf("Error on update: %v", err)
+	}
+
+	it := ndbm.NewIterator()
+	defer it.Close()
+	actual := items{}
+	for it.Next() {
+		actual = append(actual, Item{
+			Key:   append([]byte{}, it.Key()...),
+			Value: append([]byte{}, it.Value()...),
+		})
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Error on iteration: %v", err)
+	}
+	if len(expected) != len(actual) {
+		t.Fatalf(
+			"Expected and actual iteration results have different lengths: %d vs. %d",
+			len(expected), len(actual))
+	}
+	sort.Sort(actual)
+	for i, expectedItem := range expected {
+		actualItem := actual[i]
+		if bytes.Compare(expectedItem.Key, actualItem.Key) != 0 {
+			t.Errorf("Expected and actual items %d have different keys: %s vs. %s",
+				i, expectedItem.Key, actualItem.Key)
+		}
+		if bytes.Compare(expectedItem.Value, actualItem.Value) != 0 {
+			t.Errorf("Expected and actual items %d have different values: %s vs. %s",
+				i, expectedItem.Value, actualItem.Value)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "TestBatch")
+	if err != nil {
+		t.Fatalf("Couldn't create tempdir for test DB: %v", err)
+	}
+	defer os.RemoveAll(tempdir)
+	path := filepath.Join(tempdir, "test")
+
+	ndbm, err := OpenWithDefaults(path)
+	if err != nil {
+		t.Fatalf("Couldn't open DB: %v", err)
+	}
+	defer ndbm.Close()
+
+	if err := ndbm.Replace([]byte("a"), []byte("alphabet")); err != nil {
+		t.Fatalf("Error on replace: %v", err)
+	}
+
+	// A discarded batch must not touch the database.
+	discarded := ndbm.NewBatch()
+	if err := discarded.Put([]byte("b"), []byte("battlement")); err != nil {
+		t.Fatalf("Error staging put: %v", err)
+	}
+	if err := discarded.Discard(); err != nil {
+		t.Fatalf("Error on discard: %v", err)
+	}
+	if _, err := ndbm.Fetch([]byte("b")); err == nil {
+		t.Errorf("Discarded batch should not have written key b")
+	}
+
+	// A committed batch applies every staged op atomically, including a
+	// delete of a key that was written before the batch was created.
+	batch := ndbm.NewBatch()
+	if err := batch.Put([]byte("b"), []byte("battlement")); err != nil {
+		t.Fatalf("Error staging put: %v", err)
+	}
+	if err := batch.Put([]byte("c"), []byte("carnival")); err != nil {
+		t.Fatalf("Error staging put: %v", err)
+	}
+	if err := batch.Delete([]byte("a")); err != nil {
+		t.Fatalf("Error staging delete: %v", err)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Error on commit: %v", err)
+	}
+
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Errorf("Journal file should be removed after commit, stat err: %v", err)
+	}
+	if _, err := ndbm.Fetch([]byte("a")); err == nil {
+		t.Errorf("Key a should have been deleted by the batch")
+	}
+	value, err := ndbm.Fetch([]byte("b"))
+	if err != nil || bytes.Compare(value, []byte("battlement")) != 0 {
+		t.Errorf("Key b should be %q, got %q, %v", "battlement", value, err)
+	}
+	value, err = ndbm.Fetch([]byte("c"))
+	if err != nil || bytes.Compare(value, []byte("carnival")) != 0 {
+		t.Errorf("Key c should be %q, got %q, %v", "carnival", value, err)
+	}
+}
+
+func TestBatchJournalRecovery(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "TestBatchJournalRecovery")
+	if err != nil {
+		t.Fatalf("Couldn't create tempdir for test DB: %v", err)
+	}
+	defer os.RemoveAll(tempdir)
+	path := filepath.Join(tempdir, "test")
+
+	// Write a committed journal, simulating a crash between fsyncing the
+	// journal and removing it, then confirm Open replays it.
+	{
+		ndbm, err := OpenWithDefaults(path)
+		if err != nil {
+			t.Fatalf("Couldn't open DB: %v", err)
+		}
+		batch := ndbm.NewBatch()
+		if err := batch.Put([]byte("a"), []byte("alphabet")); err != nil {
+			t.Fatalf("Error staging put: %v", err)
+		}
+		journal, err := os.Create(path + ".wal")
+		if err != nil {
+			t.Fatalf("Error creating journal: %v", err)
+		}
+		for _, op := range batch.ops {
+			if err := writeRecord(journal, op); err != nil {
+				t.Fatalf("Error writing record: %v", err)
+			}
+		}
+		if _, err := journal.Write(commitMarker[:]); err != nil {
+			t.Fatalf("Error writing commit marker: %v", err)
+		}
+		journal.Close()
+		ndbm.Close()
+	}
+
+	ndbm, err := OpenWithDefaults(path)
+	if err != nil {
+		t.Fatalf("Couldn't reopen DB: %v", err)
+	}
+	defer ndbm.Close()
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Errorf("Committed journal should be removed after replay, stat err: %v", err)
+	}
+	value, err := ndbm.Fetch([]byte("a"))
+	if err != nil || bytes.Compare(value, []byte("alphabet")) != 0 {
+		t.Errorf("Replayed key a should be %q, got %q, %v", "alphabet", value, err)
+	}
+
+	// An uncommitted journal (no commit marker) must be discarded, not replayed.
+	if err := ioutil.WriteFile(path+".wal", []byte("not a valid commit marker"), 0600); err != nil {
+		t.Fatalf("Error writing uncommitted journal: %v", err)
+	}
+	ndbm.Close()
+	ndbm, err = OpenWithDefaults(path)
+	if err != nil {
+		t.Fatalf("Couldn't reopen DB: %v", err)
+	}
+	defer ndbm.Close()
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Errorf("Uncommitted journal should be discarded, stat err: %v", err)
+	}
+	if _, err := ndbm.Fetch([]byte("b")); err == nil {
+		t.Errorf("Uncommitted journal should not have been replayed")
+	}
+}
+
+func TestPrefixDB(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "TestPrefixDB")
+	if err != nil {
+		t.Fatalf("Couldn't create tempdir for test DB: %v", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	ndbm, err := OpenWithDefaults(filepath.Join(tempdir, "test"))
+	if err != nil {
+		t.Fatalf("Couldn't open DB: %v", err)
+	}
+	defer ndbm.Close()
+
+	tenants := ndbm.Prefix([]byte("tenants/"))
+	other := ndbm.Prefix([]byte("other/"))
+
+	if err := tenants.Replace([]byte("a"), []byte("alphabet")); err != nil {
+		t.Fatalf("Error on replace: %v", err)
+	}
+	if err := tenants.Insert([]byte("b"), []byte("battlement")); err != nil {
+		t.Fatalf("Error on insert: %v", err)
+	}
+	if err := other.Replace([]byte("a"), []byte("unrelated")); err != nil {
+		t.Fatalf("Error on replace: %v", err)
+	}
+
+	// Keys don't collide across prefixes, even though they're identical
+	// after stripping.
+	value, err := tenants.Fetch([]byte("a"))
+	if err != nil || bytes.Compare(value, []byte("alphabet")) != 0 {
+		t.Errorf("tenants[a] should be %q, got %q, %v", "alphabet", value, err)
+	}
+	value, err = other.Fetch([]byte("a"))
+	if err != nil || bytes.Compare(value, []byte("unrelated")) != 0 {
+		t.Errorf("other[a] should be %q, got %q, %v", "unrelated", value, err)
+	}
+
+	// Inserting a duplicate key should fail with the unprefixed key.
+	if err := tenants.Insert([]byte("a"), []byte("x")); err == nil {
+		t.Errorf("Expected error on insert of duplicate key")
+	} else if exists, ok := err.(KeyAlreadyExists); !ok || bytes.Compare(exists.Key, []byte("a")) != 0 {
+		t.Errorf("Error on insert should report unprefixed key, got: %v", err)
+	}
+
+	// Iteration only sees keys under the prefix, with the prefix stripped.
+	actual := items(tenants.Items())
+	expected := items{
+		Item{[]byte("a"), []byte("alphabet")},
+		Item{[]byte("b"), []byte("battlement")},
+	}
+	if len(expected) != len(actual) {
+		t.Fatalf("tenants.Items() has %d items, want %d: %v", len(actual), len(expected), actual)
+	}
+	sort.Sort(actual)
+	for i, expectedItem := range expected {
+		if bytes.Compare(expectedItem.Key, actual[i].Key) != 0 {
+			t.Errorf("Item %d key = %s, want %s", i, actual[i].Key, expectedItem.Key)
+		}
+		if bytes.Compare(expectedItem.Value, actual[i].Value) != 0 {
+			t.Errorf("Item %d value = %s, want %s", i, actual[i].Value, expectedItem.Value)
+		}
+	}
+
+	if err := tenants.Delete([]byte("a")); err != nil {
+		t.Fatalf("Error on delete: %v", err)
+	}
+	if _, err := tenants.Fetch([]byte("a")); err == nil {
+		t.Errorf("Expected error fetching deleted key")
+	}
+	if _, err := other.Fetch([]byte("a")); err != nil {
+		t.Errorf("Deleting tenants[a] should not affect other[a]: %v", err)
+	}
+}
+
+func TestSyncDB(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "TestSyncDB")
+	if err != nil {
+		t.Fatalf("Couldn't create tempdir for test DB: %v", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	ndbm, err := OpenWithDefaults(filepath.Join(tempdir, "test"))
+	if err != nil {
+		t.Fatalf("Couldn't open DB: %v", err)
+	}
+	sdb := NewSyncDB(ndbm)
+	defer sdb.Close()
+
+	// Phase 1: hammer the same SyncDB with concurrent writes and reads;
+	// the race detector (and NDBM itself, if the locking is wrong) will
+	// catch unsynchronized access.
+	const goroutines = 8
+	const perGoroutine = 20
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				key := []byte(fmt.Sprintf("g%d-%d", g, i))
+				if err := sdb.Replace(key, key); err != nil {
+					t.Errorf("Replace(%s): %v", key, err)
+				}
+				if _, err := sdb.Fetch(key); err != nil {
+					t.Errorf("Fetch(%s): %v", key, err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	total := goroutines * perGoroutine
+	if sdb.Len() != total {
+		t.Fatalf("SyncDB has %d keys, want %d", sdb.Len(), total)
+	}
+
+	// Phase 2: with the key set stable, iterate concurrently from many
+	// goroutines. Every iterator must see every key exactly once. NDBM's
+	// dbm_firstkey/dbm_nextkey cursor lives in one process-wide place, so
+	// if SyncDB didn't give iterators exclusive access, two "concurrent"
+	// iterators would drive and corrupt the same cursor, producing
+	// partial or duplicated key sets instead of a clean error.
+	const iterators = 8
+	wg.Add(iterators)
+	for g := 0; g < iterators; g++ {
+		go func() {
+			defer wg.Done()
+			seen := make(map[string]bool)
+			it := sdb.NewIterator()
+			for it.Next() {
+				seen[string(it.Key())] = true
+			}
+			if err := it.Err(); err != nil {
+				t.Errorf("iterator error: %v", err)
+			}
+			it.Close()
+			if len(seen) != total {
+				t.Errorf("iterator saw %d distinct keys, want %d", len(seen), total)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMapHelpers(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "TestMapHelpers")
+	if err != nil {
+		t.Fatalf("Couldn't create tempdir for test DB: %v", err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	ndbm, err := OpenWithDefaults(filepath.Join(tempdir, "test"))
+	if err != nil {
+		t.Fatalf("Couldn't open DB: %v", err)
+	}
+	defer ndbm.Close()
+
+	if ndbm.Has([]byte("a")) {
+		t.Errorf("Empty DB shouldn't have key a")
+	}
+
+	if err := ndbm.Merge(map[string][]byte{
+		"a": []byte("alphabet"),
+		"b": []byte("battlement"),
+		"c": []byte("carnival"),
+	}); err != nil {
+		t.Fatalf("Error on merge: %v", err)
+	}
+
+	if !ndbm.Has([]byte("a")) {
+		t.Errorf("DB should have key a after merge")
+	}
+
+	toMap := ndbm.ToMap()
+	want := map[string][]byte{
+		"a": []byte("alphabet"),
+		"b": []byte("battlement"),
+		"c": []byte("carnival"),
+	}
+	if len(toMap) != len(want) {
+		t.Fatalf("ToMap() has %d entries, want %d", len(toMap), len(want))
+	}
+	for k, v := range want {
+		if bytes.Compare(toMap[k], v) != 0 {
+			t.Errorf("ToMap()[%s] = %s, want %s", k, toMap[k], v)
+		}
+	}
+
+	filtered := ndbm.Filter(func(key, value []byte) bool {
+		return bytes.Compare(key, []byte("b")) != 0
+	})
+	if len(filtered) != 2 {
+		t.Fatalf("Filter() returned %d items, want 2", len(filtered))
+	}
+	for _, item := range filtered {
+		if bytes.Compare(item.Key, []byte("b")) == 0 {
+			t.Errorf("Filter() shouldn't have included key b")
+		}
+	}
+
+	inverted, err := ndbm.Invert()
+	if err != nil {
+		t.Fatalf("Error on invert: %v", err)
+	}
+	if len(inverted) != len(want) {
+		t.Fatalf("Invert() has %d entries, want %d", len(inverted), len(want))
+	}
+	if bytes.Compare(inverted["alphabet"], []byte("a")) != 0 {
+		t.Errorf(`Invert()["alphabet"] = %s, want "a"`, inverted["alphabet"])
+	}
+
+	if err := ndbm.Clear(); err != nil {
+		t.Fatalf("Error on clear: %v", err)
+	}
+	if ndbm.Len() != 0 {
+		t.Errorf("DB should be empty after Clear, has %d keys", ndbm.Len())
+	}
+	if ndbm.Has([]byte("a")) {
+		t.Errorf("DB shouldn't have key a after Clear")
+	}
+}